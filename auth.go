@@ -0,0 +1,210 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/limiter"
+)
+
+// sessionCookieName is the cookie carrying the signed session token; it's
+// also accepted as an Authorization: Bearer header for non-browser clients.
+const sessionCookieName = "session_token"
+
+// sessionTokenTTL is how long a login stays valid before the user has to
+// log in again.
+const sessionTokenTTL = 24 * time.Hour
+
+// sessionTokenClaims is the payload inside a signed session token.
+type sessionTokenClaims struct {
+	Username  string    `json:"username"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// signSessionToken produces a base64 "payload.signature" token, HMAC-signed
+// with SESSION_SECRET so it can't be forged or tampered with client-side.
+func signSessionToken(username string) (string, error) {
+	secret, err := sessionSecret()
+	if err != nil {
+		return "", err
+	}
+
+	claims := sessionTokenClaims{Username: username, ExpiresAt: time.Now().Add(sessionTokenTTL)}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", fmt.Errorf("error encoding session claims: %w", err)
+	}
+
+	encodedPayload := base64.RawURLEncoding.EncodeToString(payload)
+	return encodedPayload + "." + signPayload(secret, encodedPayload), nil
+}
+
+// verifySessionToken checks the signature and expiry on token and returns
+// the username it was issued for.
+func verifySessionToken(token string) (string, error) {
+	secret, err := sessionSecret()
+	if err != nil {
+		return "", err
+	}
+
+	encodedPayload, signature, ok := strings.Cut(token, ".")
+	if !ok {
+		return "", fmt.Errorf("malformed session token")
+	}
+
+	if !hmac.Equal([]byte(signPayload(secret, encodedPayload)), []byte(signature)) {
+		return "", fmt.Errorf("invalid session token signature")
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(encodedPayload)
+	if err != nil {
+		return "", fmt.Errorf("malformed session token payload")
+	}
+
+	var claims sessionTokenClaims
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return "", fmt.Errorf("malformed session token claims")
+	}
+
+	if time.Now().After(claims.ExpiresAt) {
+		return "", fmt.Errorf("session token expired")
+	}
+
+	return claims.Username, nil
+}
+
+func signPayload(secret []byte, payload string) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func sessionSecret() ([]byte, error) {
+	secret := os.Getenv("SESSION_SECRET")
+	if secret == "" {
+		return nil, fmt.Errorf("SESSION_SECRET environment variable not set")
+	}
+	return []byte(secret), nil
+}
+
+// checkCredentials validates a login against AUTH_USERNAME/AUTH_PASSWORD,
+// the simplest possible user store for a single-home assistant.
+func checkCredentials(username, password string) bool {
+	expectedUser := os.Getenv("AUTH_USERNAME")
+	expectedPass := os.Getenv("AUTH_PASSWORD")
+
+	return expectedUser != "" && expectedPass != "" &&
+		subtle.ConstantTimeCompare([]byte(username), []byte(expectedUser)) == 1 &&
+		subtle.ConstantTimeCompare([]byte(password), []byte(expectedPass)) == 1
+}
+
+// requireAuth reads the session token from the session cookie or an
+// Authorization: Bearer header, verifies it, and stores the session ID
+// (the authenticated username) in c.Locals for downstream handlers.
+func requireAuth(c *fiber.Ctx) error {
+	token := c.Cookies(sessionCookieName)
+	if token == "" {
+		token = strings.TrimPrefix(c.Get("Authorization"), "Bearer ")
+	}
+
+	if token == "" {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "not authenticated"})
+	}
+
+	username, err := verifySessionToken(token)
+	if err != nil {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	c.Locals("sessionID", username)
+	return c.Next()
+}
+
+// sessionIDFromContext reads the session ID requireAuth stored in c.Locals.
+func sessionIDFromContext(c *fiber.Ctx) string {
+	id, _ := c.Locals("sessionID").(string)
+	return id
+}
+
+// perSessionRateLimiter rate-limits each authenticated session
+// independently, instead of by IP, so one noisy user can't starve another
+// behind the same NAT.
+func perSessionRateLimiter() fiber.Handler {
+	return limiter.New(limiter.Config{
+		Max:        30,
+		Expiration: 1 * time.Minute,
+		KeyGenerator: func(c *fiber.Ctx) string {
+			return sessionIDFromContext(c)
+		},
+	})
+}
+
+// loginRateLimiter throttles POST /api/login by IP, since there's no
+// authenticated session yet for perSessionRateLimiter to key on, and
+// password-guessing attempts need to be slowed down regardless.
+func loginRateLimiter() fiber.Handler {
+	return limiter.New(limiter.Config{
+		Max:        10,
+		Expiration: 1 * time.Minute,
+		KeyGenerator: func(c *fiber.Ctx) string {
+			return c.IP()
+		},
+	})
+}
+
+// handleLogin handles POST /api/login, issuing a signed session cookie on
+// success.
+func handleLogin(c *fiber.Ctx) error {
+	type Request struct {
+		Username string `json:"username"`
+		Password string `json:"password"`
+	}
+
+	req := new(Request)
+	if err := c.BodyParser(req); err != nil {
+		log.Printf("Error parsing request body: %v", err)
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+
+	if !checkCredentials(req.Username, req.Password) {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "invalid credentials"})
+	}
+
+	token, err := signSessionToken(req.Username)
+	if err != nil {
+		log.Printf("Error signing session token: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	c.Cookie(&fiber.Cookie{
+		Name:     sessionCookieName,
+		Value:    token,
+		Expires:  time.Now().Add(sessionTokenTTL),
+		HTTPOnly: true,
+		SameSite: "Strict",
+	})
+
+	return c.JSON(fiber.Map{"status": "ok"})
+}
+
+// handleLogout handles POST /api/logout, clearing the session cookie.
+func handleLogout(c *fiber.Ctx) error {
+	c.Cookie(&fiber.Cookie{
+		Name:     sessionCookieName,
+		Value:    "",
+		Expires:  time.Now().Add(-time.Hour),
+		HTTPOnly: true,
+		SameSite: "Strict",
+	})
+
+	return c.JSON(fiber.Map{"status": "ok"})
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"os"
+	"testing"
+)
+
+func TestSignAndVerifySessionTokenRoundTrip(t *testing.T) {
+	t.Setenv("SESSION_SECRET", "test-secret")
+
+	token, err := signSessionToken("alice")
+	if err != nil {
+		t.Fatalf("signSessionToken: %v", err)
+	}
+
+	username, err := verifySessionToken(token)
+	if err != nil {
+		t.Fatalf("verifySessionToken: %v", err)
+	}
+	if username != "alice" {
+		t.Errorf("verifySessionToken() = %q, want %q", username, "alice")
+	}
+}
+
+func TestVerifySessionTokenRejectsTampering(t *testing.T) {
+	t.Setenv("SESSION_SECRET", "test-secret")
+
+	token, err := signSessionToken("alice")
+	if err != nil {
+		t.Fatalf("signSessionToken: %v", err)
+	}
+
+	if _, err := verifySessionToken(token + "tampered"); err == nil {
+		t.Error("verifySessionToken with tampered signature: expected error, got nil")
+	}
+}
+
+func TestVerifySessionTokenRejectsWrongSecret(t *testing.T) {
+	t.Setenv("SESSION_SECRET", "test-secret")
+	token, err := signSessionToken("alice")
+	if err != nil {
+		t.Fatalf("signSessionToken: %v", err)
+	}
+
+	os.Setenv("SESSION_SECRET", "different-secret")
+	if _, err := verifySessionToken(token); err == nil {
+		t.Error("verifySessionToken after secret rotation: expected error, got nil")
+	}
+}
+
+func TestCheckCredentials(t *testing.T) {
+	tests := []struct {
+		name    string
+		envUser string
+		envPass string
+		inUser  string
+		inPass  string
+		wantOK  bool
+	}{
+		{name: "correct credentials", envUser: "admin", envPass: "hunter2", inUser: "admin", inPass: "hunter2", wantOK: true},
+		{name: "wrong password", envUser: "admin", envPass: "hunter2", inUser: "admin", inPass: "wrong", wantOK: false},
+		{name: "wrong username", envUser: "admin", envPass: "hunter2", inUser: "eve", inPass: "hunter2", wantOK: false},
+		{name: "empty password rejected when AUTH_PASSWORD unset", envUser: "admin", envPass: "", inUser: "admin", inPass: "", wantOK: false},
+		{name: "empty username never configured", envUser: "", envPass: "hunter2", inUser: "", inPass: "hunter2", wantOK: false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Setenv("AUTH_USERNAME", tc.envUser)
+			t.Setenv("AUTH_PASSWORD", tc.envPass)
+
+			if got := checkCredentials(tc.inUser, tc.inPass); got != tc.wantOK {
+				t.Errorf("checkCredentials(%q, %q) = %v, want %v", tc.inUser, tc.inPass, got, tc.wantOK)
+			}
+		})
+	}
+}
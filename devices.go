@@ -0,0 +1,382 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/google/generative-ai-go/genai"
+)
+
+// DeviceController dispatches a function call coming back from the model to
+// whatever actually controls the home's devices.
+type DeviceController interface {
+	Invoke(ctx context.Context, name string, args map[string]any) (map[string]any, error)
+}
+
+// deviceTools are the function declarations advertised to the model so it
+// can arm/disarm the alarm, lock doors, pull camera snapshots, etc. instead
+// of just pretending it did.
+var deviceTools = []*genai.Tool{
+	{
+		FunctionDeclarations: []*genai.FunctionDeclaration{
+			{
+				Name:        "arm_alarm",
+				Description: "Arms the home security alarm system in the given mode.",
+				Parameters: &genai.Schema{
+					Type: genai.TypeObject,
+					Properties: map[string]*genai.Schema{
+						"mode": {
+							Type:        genai.TypeString,
+							Description: "Arming mode to use.",
+							Enum:        []string{"away", "home", "night"},
+						},
+					},
+					Required: []string{"mode"},
+				},
+			},
+			{
+				Name:        "disarm_alarm",
+				Description: "Disarms the home security alarm system.",
+				Parameters: &genai.Schema{
+					Type:       genai.TypeObject,
+					Properties: map[string]*genai.Schema{},
+				},
+			},
+			{
+				Name:        "lock_door",
+				Description: "Locks or unlocks a named door.",
+				Parameters: &genai.Schema{
+					Type: genai.TypeObject,
+					Properties: map[string]*genai.Schema{
+						"door": {
+							Type:        genai.TypeString,
+							Description: "Which door to operate, e.g. front_door, back_door, garage_door.",
+						},
+						"lock": {
+							Type:        genai.TypeBoolean,
+							Description: "true to lock, false to unlock.",
+						},
+					},
+					Required: []string{"door", "lock"},
+				},
+			},
+			{
+				Name:        "get_camera_snapshot",
+				Description: "Requests a fresh snapshot from a named camera and returns a URL for it.",
+				Parameters: &genai.Schema{
+					Type: genai.TypeObject,
+					Properties: map[string]*genai.Schema{
+						"camera": {
+							Type:        genai.TypeString,
+							Description: "Name of the camera, e.g. front_door, driveway, backyard.",
+						},
+					},
+					Required: []string{"camera"},
+				},
+			},
+			{
+				Name:        "list_sensors",
+				Description: "Lists known sensors and their current state (open/closed, motion, battery).",
+				Parameters: &genai.Schema{
+					Type:       genai.TypeObject,
+					Properties: map[string]*genai.Schema{},
+				},
+			},
+			{
+				Name:        "set_zone_mode",
+				Description: "Sets the monitoring mode for a named security zone.",
+				Parameters: &genai.Schema{
+					Type: genai.TypeObject,
+					Properties: map[string]*genai.Schema{
+						"zone": {
+							Type:        genai.TypeString,
+							Description: "Zone name, e.g. perimeter, garage, backyard.",
+						},
+						"mode": {
+							Type:        genai.TypeString,
+							Description: "Monitoring mode for the zone.",
+							Enum:        []string{"monitored", "bypassed"},
+						},
+					},
+					Required: []string{"zone", "mode"},
+				},
+			},
+		},
+	},
+}
+
+// maxFunctionCallTurns caps how many function-call round trips we'll follow
+// in a single user turn before giving up, in case the model keeps calling
+// tools instead of producing a final answer.
+const maxFunctionCallTurns = 5
+
+// runChatTurn sends parts to cs, and keeps dispatching function calls to
+// controller until the model replies with text or we hit
+// maxFunctionCallTurns.
+func runChatTurn(ctx context.Context, cs *genai.ChatSession, controller DeviceController, parts ...genai.Part) (string, error) {
+	resp, err := cs.SendMessage(ctx, parts...)
+	if err != nil {
+		return "", fmt.Errorf("error sending message: %w", err)
+	}
+
+	for turn := 0; ; turn++ {
+		text, calls, err := extractResponse(resp)
+		if err != nil {
+			return "", err
+		}
+
+		if len(calls) == 0 {
+			return text, nil
+		}
+
+		if turn == maxFunctionCallTurns {
+			break
+		}
+
+		var responseParts []genai.Part
+		for _, call := range calls {
+			result, err := controller.Invoke(ctx, call.Name, call.Args)
+			if err != nil {
+				result = map[string]any{"error": err.Error()}
+			}
+			responseParts = append(responseParts, genai.FunctionResponse{
+				Name:     call.Name,
+				Response: result,
+			})
+		}
+
+		resp, err = cs.SendMessage(ctx, responseParts...)
+		if err != nil {
+			return "", fmt.Errorf("error sending function response: %w", err)
+		}
+	}
+
+	return "", fmt.Errorf("too many function call turns without a final answer")
+}
+
+// blockedContentError reports that Gemini refused to generate a response
+// because of its safety settings, so callers can surface a distinct error
+// code instead of a generic failure.
+type blockedContentError struct {
+	Reason        string
+	SafetyRatings []*genai.SafetyRating
+}
+
+func (e *blockedContentError) Error() string {
+	return fmt.Sprintf("response blocked by safety settings: %s", e.Reason)
+}
+
+// extractResponse pulls the text and any function calls out of a Gemini
+// response. A response may contain either, but for our flow it's always one
+// or the other.
+func extractResponse(resp *genai.GenerateContentResponse) (string, []genai.FunctionCall, error) {
+	if len(resp.Candidates) == 0 || resp.Candidates[0].Content == nil {
+		if resp.PromptFeedback != nil && resp.PromptFeedback.BlockReason != genai.BlockReasonUnspecified {
+			return "", nil, &blockedContentError{Reason: resp.PromptFeedback.BlockReason.String()}
+		}
+		return "", nil, fmt.Errorf("no valid candidates found in response")
+	}
+
+	if resp.Candidates[0].FinishReason == genai.FinishReasonSafety {
+		return "", nil, &blockedContentError{
+			Reason:        resp.Candidates[0].FinishReason.String(),
+			SafetyRatings: resp.Candidates[0].SafetyRatings,
+		}
+	}
+
+	var text string
+	var calls []genai.FunctionCall
+	for _, part := range resp.Candidates[0].Content.Parts {
+		switch p := part.(type) {
+		case genai.Text:
+			text += string(p)
+		case genai.FunctionCall:
+			calls = append(calls, p)
+		}
+	}
+
+	if text == "" && len(calls) == 0 {
+		return "", nil, fmt.Errorf("no valid candidates found in response")
+	}
+
+	return text, calls, nil
+}
+
+// MockDeviceController is an in-memory DeviceController for tests and local
+// development that don't have a real Home Assistant instance to talk to.
+type MockDeviceController struct {
+	mu    sync.Mutex
+	armed bool
+	mode  string
+	doors map[string]bool
+	zones map[string]string
+}
+
+// NewMockDeviceController returns a MockDeviceController with a few doors
+// pre-seeded so list_sensors/lock_door have something to report.
+func NewMockDeviceController() *MockDeviceController {
+	return &MockDeviceController{
+		doors: map[string]bool{
+			"front_door":  true,
+			"back_door":   true,
+			"garage_door": true,
+		},
+		zones: map[string]string{},
+	}
+}
+
+func (m *MockDeviceController) Invoke(ctx context.Context, name string, args map[string]any) (map[string]any, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	switch name {
+	case "arm_alarm":
+		mode, _ := args["mode"].(string)
+		m.armed = true
+		m.mode = mode
+		return map[string]any{"status": "armed", "mode": mode}, nil
+	case "disarm_alarm":
+		m.armed = false
+		m.mode = ""
+		return map[string]any{"status": "disarmed"}, nil
+	case "lock_door":
+		door, _ := args["door"].(string)
+		lock, _ := args["lock"].(bool)
+		if _, ok := m.doors[door]; !ok {
+			return nil, fmt.Errorf("unknown door %q", door)
+		}
+		m.doors[door] = lock
+		return map[string]any{"door": door, "locked": lock}, nil
+	case "get_camera_snapshot":
+		camera, _ := args["camera"].(string)
+		return map[string]any{"camera": camera, "snapshot_url": fmt.Sprintf("https://example.com/snapshots/%s/latest.jpg", camera)}, nil
+	case "list_sensors":
+		sensors := make(map[string]any, len(m.doors))
+		for door, locked := range m.doors {
+			sensors[door] = map[string]any{"locked": locked}
+		}
+		return map[string]any{"sensors": sensors}, nil
+	case "set_zone_mode":
+		zone, _ := args["zone"].(string)
+		mode, _ := args["mode"].(string)
+		m.zones[zone] = mode
+		return map[string]any{"zone": zone, "mode": mode}, nil
+	default:
+		return nil, fmt.Errorf("unknown device action %q", name)
+	}
+}
+
+// HomeAssistantController drives real devices through Home Assistant's REST
+// API (see https://developers.home-assistant.io/docs/api/rest/).
+type HomeAssistantController struct {
+	BaseURL    string
+	Token      string
+	HTTPClient *http.Client
+}
+
+// NewHomeAssistantController builds a HomeAssistantController pointed at a
+// Home Assistant instance, e.g. baseURL "http://homeassistant.local:8123".
+func NewHomeAssistantController(baseURL, token string) *HomeAssistantController {
+	return &HomeAssistantController{
+		BaseURL:    baseURL,
+		Token:      token,
+		HTTPClient: http.DefaultClient,
+	}
+}
+
+func (h *HomeAssistantController) Invoke(ctx context.Context, name string, args map[string]any) (map[string]any, error) {
+	switch name {
+	case "arm_alarm":
+		mode, _ := args["mode"].(string)
+		service := "alarm_arm_away"
+		switch mode {
+		case "home":
+			service = "alarm_arm_home"
+		case "night":
+			service = "alarm_arm_night"
+		}
+		return h.callService(ctx, "alarm_control_panel", service, map[string]any{"entity_id": "alarm_control_panel.home"})
+	case "disarm_alarm":
+		return h.callService(ctx, "alarm_control_panel", "alarm_disarm", map[string]any{"entity_id": "alarm_control_panel.home"})
+	case "lock_door":
+		door, _ := args["door"].(string)
+		lock, _ := args["lock"].(bool)
+		service := "unlock"
+		if lock {
+			service = "lock"
+		}
+		return h.callService(ctx, "lock", service, map[string]any{"entity_id": "lock." + door})
+	case "get_camera_snapshot":
+		camera, _ := args["camera"].(string)
+		return h.callService(ctx, "camera", "snapshot", map[string]any{"entity_id": "camera." + camera})
+	case "list_sensors":
+		return h.getStates(ctx)
+	case "set_zone_mode":
+		zone, _ := args["zone"].(string)
+		mode, _ := args["mode"].(string)
+		service := "turn_on"
+		if mode == "bypassed" {
+			service = "turn_off"
+		}
+		return h.callService(ctx, "switch", service, map[string]any{"entity_id": "switch." + zone + "_monitoring"})
+	default:
+		return nil, fmt.Errorf("unknown device action %q", name)
+	}
+}
+
+func (h *HomeAssistantController) callService(ctx context.Context, domain, service string, payload map[string]any) (map[string]any, error) {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("error marshaling home assistant payload: %w", err)
+	}
+
+	url := fmt.Sprintf("%s/api/services/%s/%s", h.BaseURL, domain, service)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("error building home assistant request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+h.Token)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := h.HTTPClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error calling home assistant: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("home assistant returned status %d", resp.StatusCode)
+	}
+
+	return map[string]any{"status": "ok"}, nil
+}
+
+func (h *HomeAssistantController) getStates(ctx context.Context) (map[string]any, error) {
+	url := fmt.Sprintf("%s/api/states", h.BaseURL)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error building home assistant request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+h.Token)
+
+	resp, err := h.HTTPClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error calling home assistant: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("home assistant returned status %d", resp.StatusCode)
+	}
+
+	var states []map[string]any
+	if err := json.NewDecoder(resp.Body).Decode(&states); err != nil {
+		return nil, fmt.Errorf("error decoding home assistant states: %w", err)
+	}
+
+	return map[string]any{"sensors": states}, nil
+}
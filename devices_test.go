@@ -0,0 +1,52 @@
+package main
+
+import (
+	"context"
+	"testing"
+)
+
+func TestMockDeviceControllerArmAndDisarm(t *testing.T) {
+	m := NewMockDeviceController()
+	ctx := context.Background()
+
+	result, err := m.Invoke(ctx, "arm_alarm", map[string]any{"mode": "away"})
+	if err != nil {
+		t.Fatalf("arm_alarm: %v", err)
+	}
+	if result["status"] != "armed" || result["mode"] != "away" {
+		t.Errorf("arm_alarm result = %v, want status=armed mode=away", result)
+	}
+
+	result, err = m.Invoke(ctx, "disarm_alarm", nil)
+	if err != nil {
+		t.Fatalf("disarm_alarm: %v", err)
+	}
+	if result["status"] != "disarmed" {
+		t.Errorf("disarm_alarm result = %v, want status=disarmed", result)
+	}
+}
+
+func TestMockDeviceControllerLockDoor(t *testing.T) {
+	m := NewMockDeviceController()
+	ctx := context.Background()
+
+	result, err := m.Invoke(ctx, "lock_door", map[string]any{"door": "front_door", "lock": false})
+	if err != nil {
+		t.Fatalf("lock_door: %v", err)
+	}
+	if result["locked"] != false {
+		t.Errorf("lock_door result = %v, want locked=false", result)
+	}
+
+	if _, err := m.Invoke(ctx, "lock_door", map[string]any{"door": "side_gate", "lock": true}); err == nil {
+		t.Error("lock_door on unknown door: expected error, got nil")
+	}
+}
+
+func TestMockDeviceControllerUnknownAction(t *testing.T) {
+	m := NewMockDeviceController()
+
+	if _, err := m.Invoke(context.Background(), "launch_missiles", nil); err == nil {
+		t.Error("Invoke with unknown action: expected error, got nil")
+	}
+}
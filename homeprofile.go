@@ -0,0 +1,170 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/generative-ai-go/genai"
+)
+
+// homeProfilePath is where the per-home profile is persisted between
+// restarts.
+const homeProfilePath = "home_profile.json"
+
+// cacheTTL is how long a CachedContent lives before it needs recreating.
+const cacheTTL = 1 * time.Hour
+
+// cacheRefreshMargin is how long before expiry we proactively recreate the
+// cached content, so a request never races an expiring cache.
+const cacheRefreshMargin = 5 * time.Minute
+
+// homeProfile captures the installed devices, floor plan notes, and
+// resident preferences for a single home, baked into the long-lived system
+// instruction via context caching.
+type homeProfile struct {
+	Devices     []string `json:"devices"`
+	FloorPlan   string   `json:"floor_plan_notes"`
+	Preferences string   `json:"resident_preferences"`
+}
+
+func loadHomeProfile() (homeProfile, error) {
+	data, err := os.ReadFile(homeProfilePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return homeProfile{}, nil
+		}
+		return homeProfile{}, fmt.Errorf("error reading home profile: %w", err)
+	}
+
+	var profile homeProfile
+	if err := json.Unmarshal(data, &profile); err != nil {
+		return homeProfile{}, fmt.Errorf("error parsing home profile: %w", err)
+	}
+
+	return profile, nil
+}
+
+func saveHomeProfile(profile homeProfile) error {
+	data, err := json.Marshal(profile)
+	if err != nil {
+		return fmt.Errorf("error serializing home profile: %w", err)
+	}
+
+	if err := os.WriteFile(homeProfilePath, data, 0o644); err != nil {
+		return fmt.Errorf("error writing home profile: %w", err)
+	}
+
+	return nil
+}
+
+// cachedContentState tracks the CachedContent currently in use so we only
+// recreate it when the home profile changes or it's about to expire.
+var cachedContentState struct {
+	mu          sync.Mutex
+	content     *genai.CachedContent
+	profileHash string
+	expiresAt   time.Time
+}
+
+// ensureCachedContent returns a CachedContent covering the system
+// instruction and profile, transparently recreating it when the profile
+// hash has changed or the existing cache is near expiry.
+func ensureCachedContent(ctx context.Context, profile homeProfile) (*genai.CachedContent, error) {
+	hash := profileHash(profile)
+
+	cachedContentState.mu.Lock()
+	defer cachedContentState.mu.Unlock()
+
+	if cachedContentState.content != nil &&
+		cachedContentState.profileHash == hash &&
+		time.Until(cachedContentState.expiresAt) > cacheRefreshMargin {
+		return cachedContentState.content, nil
+	}
+
+	if err := ensureClient(ctx); err != nil {
+		return nil, err
+	}
+
+	cc, err := client.CreateCachedContent(ctx, &genai.CachedContent{
+		Model:             geminiModelName,
+		SystemInstruction: &genai.Content{Parts: []genai.Part{genai.Text(systemInstructionText(profile))}},
+		Tools:             deviceTools,
+		Expiration:        genai.ExpireTimeOrTTL{TTL: cacheTTL},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error creating cached content: %w", err)
+	}
+
+	cachedContentState.content = cc
+	cachedContentState.profileHash = hash
+	cachedContentState.expiresAt = time.Now().Add(cacheTTL)
+
+	return cc, nil
+}
+
+func profileHash(profile homeProfile) string {
+	data, _ := json.Marshal(profile)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// systemInstructionText builds the full system instruction, folding the
+// home profile in underneath the base security-assistant preamble.
+func systemInstructionText(profile homeProfile) string {
+	instruction := "You are a specialized AI assistant for home security systems. Answer the following question about home security. If the question is not related to home security, politely decline to answer and explain that you only answer questions about home security systems, cameras, alarms, sensors, etc. Keep responses concise, informative, and helpful for home owners. If the user asks you to control a home security device, use the provided tools to actually do it."
+
+	if len(profile.Devices) == 0 && profile.FloorPlan == "" && profile.Preferences == "" {
+		return instruction
+	}
+
+	var b strings.Builder
+	b.WriteString(instruction)
+	b.WriteString("\n\nHome profile for this installation:\n")
+	if len(profile.Devices) > 0 {
+		b.WriteString("Installed devices: " + strings.Join(profile.Devices, ", ") + "\n")
+	}
+	if profile.FloorPlan != "" {
+		b.WriteString("Floor plan notes: " + profile.FloorPlan + "\n")
+	}
+	if profile.Preferences != "" {
+		b.WriteString("Resident preferences: " + profile.Preferences + "\n")
+	}
+
+	return b.String()
+}
+
+// handleGetHomeProfile handles GET /api/home-profile.
+func handleGetHomeProfile(c *fiber.Ctx) error {
+	profile, err := loadHomeProfile()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	return c.JSON(profile)
+}
+
+// handleUpdateHomeProfile handles PUT /api/home-profile, letting an
+// installer record the devices, floor plan, and preferences for this home.
+func handleUpdateHomeProfile(c *fiber.Ctx) error {
+	profile := new(homeProfile)
+	if err := c.BodyParser(profile); err != nil {
+		log.Printf("Error parsing request body: %v", err)
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+
+	if err := saveHomeProfile(*profile); err != nil {
+		log.Printf("Error saving home profile: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	return c.JSON(profile)
+}
@@ -0,0 +1,67 @@
+package knowledge
+
+import (
+	"bufio"
+	"strings"
+)
+
+// chunkSize is the target number of words per chunk; manuals are split by
+// paragraph and then merged up to roughly this size so each chunk's
+// embedding stays focused on a single topic.
+const chunkSize = 200
+
+// ChunkText splits raw document text (markdown, or plain text already
+// extracted from a PDF) into chunks suitable for embedding.
+func ChunkText(text string) []string {
+	paragraphs := splitParagraphs(text)
+
+	var chunks []string
+	var current strings.Builder
+	wordCount := 0
+
+	flush := func() {
+		if current.Len() > 0 {
+			chunks = append(chunks, strings.TrimSpace(current.String()))
+			current.Reset()
+			wordCount = 0
+		}
+	}
+
+	for _, p := range paragraphs {
+		words := len(strings.Fields(p))
+		if wordCount+words > chunkSize && wordCount > 0 {
+			flush()
+		}
+		current.WriteString(p)
+		current.WriteString("\n\n")
+		wordCount += words
+	}
+	flush()
+
+	return chunks
+}
+
+func splitParagraphs(text string) []string {
+	var paragraphs []string
+	var current strings.Builder
+
+	scanner := bufio.NewScanner(strings.NewReader(text))
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.TrimSpace(line) == "" {
+			if current.Len() > 0 {
+				paragraphs = append(paragraphs, current.String())
+				current.Reset()
+			}
+			continue
+		}
+		current.WriteString(line)
+		current.WriteString(" ")
+	}
+	if current.Len() > 0 {
+		paragraphs = append(paragraphs, current.String())
+	}
+
+	return paragraphs
+}
@@ -0,0 +1,37 @@
+package knowledge
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestChunkTextSplitsOnParagraphBoundaries(t *testing.T) {
+	text := "First paragraph.\n\nSecond paragraph."
+
+	chunks := ChunkText(text)
+
+	if len(chunks) != 1 {
+		t.Fatalf("ChunkText() = %d chunks, want 1 (both paragraphs fit under chunkSize)", len(chunks))
+	}
+	if !strings.Contains(chunks[0], "First paragraph.") || !strings.Contains(chunks[0], "Second paragraph.") {
+		t.Errorf("ChunkText() chunk = %q, want both paragraphs present", chunks[0])
+	}
+}
+
+func TestChunkTextFlushesBeforeExceedingChunkSize(t *testing.T) {
+	word := "lorem "
+	big := strings.Repeat(word, chunkSize-1)
+	text := big + "\n\n" + big
+
+	chunks := ChunkText(text)
+
+	if len(chunks) != 2 {
+		t.Fatalf("ChunkText() = %d chunks, want 2 (second paragraph should overflow into its own chunk)", len(chunks))
+	}
+}
+
+func TestChunkTextEmptyInput(t *testing.T) {
+	if chunks := ChunkText(""); len(chunks) != 0 {
+		t.Errorf("ChunkText(\"\") = %v, want no chunks", chunks)
+	}
+}
@@ -0,0 +1,91 @@
+package knowledge
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/google/generative-ai-go/genai"
+)
+
+// EmbeddingModelName is the Gemini embedding model used for both ingestion
+// and query-time retrieval.
+const EmbeddingModelName = "text-embedding-004"
+
+// Manager ingests documents into a Store and retrieves the chunks most
+// relevant to a user's query at answer time.
+type Manager struct {
+	client *genai.Client
+	store  Store
+}
+
+// NewManager builds a Manager backed by the given genai client and Store.
+func NewManager(client *genai.Client, store Store) *Manager {
+	return &Manager{client: client, store: store}
+}
+
+// IngestDocument chunks text, embeds each chunk with Gemini's embedding
+// model, and stores the result under docID so it can later be removed as a
+// unit via DeleteDocument.
+func (m *Manager) IngestDocument(ctx context.Context, docID, text string) (int, error) {
+	chunks := ChunkText(text)
+	if len(chunks) == 0 {
+		return 0, fmt.Errorf("document produced no chunks")
+	}
+
+	em := m.client.EmbeddingModel(EmbeddingModelName)
+	batch := em.NewBatch()
+	for _, c := range chunks {
+		batch.AddContent(genai.Text(c))
+	}
+
+	resp, err := em.BatchEmbedContents(ctx, batch)
+	if err != nil {
+		return 0, fmt.Errorf("error embedding document chunks: %w", err)
+	}
+
+	for i, embedding := range resp.Embeddings {
+		if err := m.store.Add(Chunk{
+			ID:        fmt.Sprintf("%s-%d", docID, i),
+			DocID:     docID,
+			Text:      chunks[i],
+			Embedding: embedding.Values,
+		}); err != nil {
+			return i, fmt.Errorf("error storing chunk %d: %w", i, err)
+		}
+	}
+
+	return len(chunks), nil
+}
+
+// DeleteDocument removes every chunk previously ingested under docID.
+func (m *Manager) DeleteDocument(docID string) error {
+	return m.store.DeleteDoc(docID)
+}
+
+// Retrieve embeds query and returns the topK most similar chunks, ready to
+// be prepended as grounding context ahead of the user's message.
+func (m *Manager) Retrieve(ctx context.Context, query string, topK int) ([]Chunk, error) {
+	em := m.client.EmbeddingModel(EmbeddingModelName)
+
+	resp, err := em.EmbedContent(ctx, genai.Text(query))
+	if err != nil {
+		return nil, fmt.Errorf("error embedding query: %w", err)
+	}
+
+	return m.store.TopK(resp.Embedding.Values, topK)
+}
+
+// GroundingContext formats chunks into a single block of text to prepend
+// to a chat turn ahead of the user's own message.
+func GroundingContext(chunks []Chunk) string {
+	if len(chunks) == 0 {
+		return ""
+	}
+
+	text := "Relevant excerpts from the installed hardware's manuals:\n\n"
+	for _, c := range chunks {
+		text += "- " + c.Text + "\n\n"
+	}
+
+	return text
+}
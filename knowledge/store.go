@@ -0,0 +1,145 @@
+// Package knowledge implements retrieval-augmented grounding over ingested
+// home-security hardware manuals: chunking, embedding, and similarity
+// search over the chunks.
+package knowledge
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"sync"
+)
+
+// Chunk is a single piece of ingested document text along with its
+// embedding vector.
+type Chunk struct {
+	ID        string    `json:"id"`
+	DocID     string    `json:"doc_id"`
+	Text      string    `json:"text"`
+	Embedding []float32 `json:"embedding"`
+}
+
+// Store persists chunks and finds the ones most similar to a query
+// embedding. The default FlatFileStore is a simple cosine-similarity scan
+// backed by a JSON file; swap in a pgvector- or SQLite-vec-backed
+// implementation for larger knowledge bases.
+type Store interface {
+	Add(chunk Chunk) error
+	DeleteDoc(docID string) error
+	TopK(queryEmbedding []float32, k int) ([]Chunk, error)
+}
+
+// FlatFileStore is a Store that keeps all chunks in memory and persists
+// them to a single JSON file on every mutation.
+type FlatFileStore struct {
+	path string
+
+	mu     sync.Mutex
+	chunks []Chunk
+}
+
+// NewFlatFileStore loads chunks from path if it exists, or starts empty.
+func NewFlatFileStore(path string) (*FlatFileStore, error) {
+	s := &FlatFileStore{path: path}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return s, nil
+		}
+		return nil, fmt.Errorf("error reading knowledge store: %w", err)
+	}
+
+	if err := json.Unmarshal(data, &s.chunks); err != nil {
+		return nil, fmt.Errorf("error parsing knowledge store: %w", err)
+	}
+
+	return s, nil
+}
+
+func (s *FlatFileStore) Add(chunk Chunk) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.chunks = append(s.chunks, chunk)
+	return s.save()
+}
+
+func (s *FlatFileStore) DeleteDoc(docID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	kept := s.chunks[:0]
+	for _, c := range s.chunks {
+		if c.DocID != docID {
+			kept = append(kept, c)
+		}
+	}
+	s.chunks = kept
+
+	return s.save()
+}
+
+func (s *FlatFileStore) TopK(queryEmbedding []float32, k int) ([]Chunk, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	type scored struct {
+		chunk Chunk
+		score float64
+	}
+
+	scoredChunks := make([]scored, 0, len(s.chunks))
+	for _, c := range s.chunks {
+		scoredChunks = append(scoredChunks, scored{chunk: c, score: cosineSimilarity(queryEmbedding, c.Embedding)})
+	}
+
+	sort.Slice(scoredChunks, func(i, j int) bool {
+		return scoredChunks[i].score > scoredChunks[j].score
+	})
+
+	if k > len(scoredChunks) {
+		k = len(scoredChunks)
+	}
+
+	top := make([]Chunk, k)
+	for i := 0; i < k; i++ {
+		top[i] = scoredChunks[i].chunk
+	}
+
+	return top, nil
+}
+
+func (s *FlatFileStore) save() error {
+	data, err := json.Marshal(s.chunks)
+	if err != nil {
+		return fmt.Errorf("error serializing knowledge store: %w", err)
+	}
+
+	if err := os.WriteFile(s.path, data, 0o644); err != nil {
+		return fmt.Errorf("error writing knowledge store: %w", err)
+	}
+
+	return nil
+}
+
+func cosineSimilarity(a, b []float32) float64 {
+	if len(a) == 0 || len(b) == 0 || len(a) != len(b) {
+		return -1
+	}
+
+	var dot, magA, magB float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		magA += float64(a[i]) * float64(a[i])
+		magB += float64(b[i]) * float64(b[i])
+	}
+
+	if magA == 0 || magB == 0 {
+		return 0
+	}
+
+	return dot / (math.Sqrt(magA) * math.Sqrt(magB))
+}
@@ -0,0 +1,45 @@
+package knowledge
+
+import "testing"
+
+func TestCosineSimilarityIdenticalVectors(t *testing.T) {
+	a := []float32{1, 2, 3}
+
+	if got := cosineSimilarity(a, a); got < 0.999 || got > 1.001 {
+		t.Errorf("cosineSimilarity(a, a) = %v, want ~1", got)
+	}
+}
+
+func TestCosineSimilarityOrthogonalVectors(t *testing.T) {
+	a := []float32{1, 0}
+	b := []float32{0, 1}
+
+	if got := cosineSimilarity(a, b); got < -0.001 || got > 0.001 {
+		t.Errorf("cosineSimilarity(orthogonal) = %v, want ~0", got)
+	}
+}
+
+func TestCosineSimilarityMismatchedLengths(t *testing.T) {
+	a := []float32{1, 2, 3}
+	b := []float32{1, 2}
+
+	if got := cosineSimilarity(a, b); got != -1 {
+		t.Errorf("cosineSimilarity(mismatched lengths) = %v, want -1", got)
+	}
+}
+
+func TestFlatFileStoreTopK(t *testing.T) {
+	s := &FlatFileStore{chunks: []Chunk{
+		{ID: "a", Embedding: []float32{1, 0}},
+		{ID: "b", Embedding: []float32{0, 1}},
+		{ID: "c", Embedding: []float32{0.9, 0.1}},
+	}}
+
+	top, err := s.TopK([]float32{1, 0}, 2)
+	if err != nil {
+		t.Fatalf("TopK: %v", err)
+	}
+	if len(top) != 2 || top[0].ID != "a" || top[1].ID != "c" {
+		t.Errorf("TopK() = %+v, want [a, c] in that order", top)
+	}
+}
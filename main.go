@@ -2,9 +2,12 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"log"
 	"os"
-	"sync"
+	"strings"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/logger"
@@ -12,30 +15,145 @@ import (
 	"github.com/google/generative-ai-go/genai"
 	"github.com/joho/godotenv"
 	"google.golang.org/api/option"
+
+	"github.com/prajjwal000/Home-Security-Chatbot/knowledge"
 )
 
+// geminiModelName is the Gemini model used for chat turns and for the
+// secondary summarization calls made when trimming session history.
+const geminiModelName = "gemini-2.0-flash"
+
+// knowledgeTopK is how many grounding chunks to retrieve per turn.
+const knowledgeTopK = 3
+
+// knowledgeStorePath is where the flat-file vector store persists ingested
+// chunks between restarts.
+const knowledgeStorePath = "knowledge_store.json"
+
+// defaultSessionIdleTTL is how long a session can sit idle before the
+// background sweeper expires it. Override with SESSION_IDLE_TTL (a
+// time.ParseDuration string, e.g. "2h").
+const defaultSessionIdleTTL = 24 * time.Hour
+
+// sessionSweepInterval is how often the sweeper checks for idle sessions.
+const sessionSweepInterval = 10 * time.Minute
+
 var (
-	chatSessions = sync.Map{}
-	client       *genai.Client
-	clientErr    error
+	client              *genai.Client
+	clientErr           error
+	deviceController    DeviceController = NewMockDeviceController()
+	knowledgeManager    *knowledge.Manager
+	knowledgeManagerErr error
+	sessionStore        SessionStore = NewMemorySessionStore()
 )
 
+// knowledgeManagerInstance lazily builds the knowledge.Manager, reusing the
+// shared Gemini client for embeddings.
+func knowledgeManagerInstance(ctx context.Context) (*knowledge.Manager, error) {
+	if knowledgeManager != nil || knowledgeManagerErr != nil {
+		return knowledgeManager, knowledgeManagerErr
+	}
+
+	if err := ensureClient(ctx); err != nil {
+		return nil, err
+	}
+
+	store, err := knowledge.NewFlatFileStore(knowledgeStorePath)
+	if err != nil {
+		knowledgeManagerErr = err
+		return nil, err
+	}
+
+	knowledgeManager = knowledge.NewManager(client, store)
+	return knowledgeManager, nil
+}
+
+// initSessionStore picks a SessionStore implementation based on
+// SESSION_STORE (memory, bolt, postgres), defaulting to the in-memory one.
+func initSessionStore() error {
+	switch os.Getenv("SESSION_STORE") {
+	case "bolt":
+		path := os.Getenv("SESSION_STORE_PATH")
+		if path == "" {
+			path = "sessions.db"
+		}
+		store, err := NewBoltSessionStore(path)
+		if err != nil {
+			return err
+		}
+		sessionStore = store
+	case "postgres":
+		store, err := NewPostgresSessionStore(os.Getenv("SESSION_STORE_DSN"))
+		if err != nil {
+			return err
+		}
+		sessionStore = store
+	default:
+		sessionStore = NewMemorySessionStore()
+	}
+
+	return nil
+}
+
+// sweepIdleSessions periodically expires sessions that haven't been active
+// for the configured idle TTL, so abandoned conversations don't accumulate
+// forever in the store.
+func sweepIdleSessions() {
+	idleTTL := defaultSessionIdleTTL
+	if raw := os.Getenv("SESSION_IDLE_TTL"); raw != "" {
+		if parsed, err := time.ParseDuration(raw); err == nil {
+			idleTTL = parsed
+		}
+	}
+
+	ticker := time.NewTicker(sessionSweepInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if err := sessionStore.Sweep(context.Background(), idleTTL); err != nil {
+			log.Printf("Error sweeping idle sessions: %v", err)
+		}
+	}
+}
+
 func main() {
 	err := godotenv.Load()
 	if err != nil {
-		fmt.Errorf("Error loading .env file: %w", err)
+		log.Printf("Error loading .env file: %v", err)
+	}
+
+	if err := initSessionStore(); err != nil {
+		log.Printf("Error initializing session store: %v", err)
 	}
+	go sweepIdleSessions()
 
 	app := fiber.New(fiber.Config{
-		AppName: "Home Security Assistant",
+		AppName:   "Home Security Assistant",
+		BodyLimit: maxRequestBodyBytes,
 	})
 
 	app.Use(logger.New())
 	app.Use(recover.New())
 
+	if haURL, ok := os.LookupEnv("HOME_ASSISTANT_URL"); ok {
+		deviceController = NewHomeAssistantController(haURL, os.Getenv("HOME_ASSISTANT_TOKEN"))
+	}
+
 	app.Static("/", "./static")
 
-	app.Post("/api/chat", handleChat)
+	app.Post("/api/login", loginRateLimiter(), handleLogin)
+	app.Post("/api/logout", handleLogout)
+
+	chatAPI := app.Group("/api", requireAuth, perSessionRateLimiter())
+	chatAPI.Post("/chat", handleChat)
+	chatAPI.Post("/chat/stream", handleChatStream)
+	chatAPI.Get("/chat/usage", handleChatUsage)
+	chatAPI.Get("/chat/history", handleChatHistory)
+	chatAPI.Get("/models", handleListModels)
+	chatAPI.Post("/knowledge", handleKnowledgeIngest)
+	chatAPI.Delete("/knowledge/:id", handleKnowledgeDelete)
+	chatAPI.Get("/home-profile", handleGetHomeProfile)
+	chatAPI.Put("/home-profile", handleUpdateHomeProfile)
 
 	port, ok := os.LookupEnv("PORT")
 	if !ok {
@@ -48,86 +166,275 @@ func main() {
 func handleChat(c *fiber.Ctx) error {
 	type Request struct {
 		Message string `json:"message"`
+		Image   string `json:"image"` // base64-encoded image, optionally a data: URL
+		Model   string `json:"model"` // optional; must be on the ALLOWED_MODELS allowlist
 	}
 
 	req := new(Request)
 
 	if err := c.BodyParser(req); err != nil {
-		fmt.Errorf("Error parsing request body: %w", err)
+		log.Printf("Error parsing request body: %v", err)
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
 	}
 
-	ip := c.IP()
+	modelName, err := resolveModel(req.Model)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	sessionID := sessionIDFromContext(c)
 
-	response, err := generateGeminiResponse(ip, req.Message)
+	response, err := generateGeminiResponse(sessionID, req.Message, req.Image, modelName)
 	if err != nil {
-		fmt.Errorf("Error generating Gemini response: %w", err)
+		log.Printf("Error generating Gemini response: %v", err)
+
+		var blocked *blockedContentError
+		if errors.As(err, &blocked) {
+			return c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{"error": err.Error(), "code": "content_blocked", "safety_ratings": blocked.SafetyRatings})
+		}
+
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
 	}
 
+	c.Set("X-Chat-Tokens-Used", fmt.Sprintf("%d", usageFor(context.Background(), sessionID).TokensUsed))
+
 	return c.JSON(fiber.Map{"response": response})
 }
 
-func generateGeminiResponse(ip, userInput string) (string, error) {
+// ensureClient lazily initializes the shared Gemini client the first time
+// it's needed, for chat sessions and for knowledge base embeddings alike.
+func ensureClient(ctx context.Context) error {
 	apiKey, ok := os.LookupEnv("GEMINI_API_KEY")
 	if !ok {
-		return "", fmt.Errorf("GEMINI_API_KEY environment variable not set")
+		return fmt.Errorf("GEMINI_API_KEY environment variable not set")
 	}
 
-	ctx := context.Background()
-
 	if client == nil && clientErr == nil {
 		client, clientErr = genai.NewClient(ctx, option.WithAPIKey(apiKey))
 		if clientErr != nil {
-			return "", fmt.Errorf("Error creating AI client: %w", clientErr)
+			return fmt.Errorf("Error creating AI client: %w", clientErr)
 		}
 	}
 
 	if clientErr != nil {
-		return "", fmt.Errorf("Error creating AI client: %w", clientErr)
+		return fmt.Errorf("Error creating AI client: %w", clientErr)
+	}
+
+	return nil
+}
+
+// chatSessionFor loads sessionID's stored state (creating a fresh one if
+// this is its first turn) and builds a genai.ChatSession and configured
+// model ready to continue the conversation, using modelName (one of
+// allowedModels) for this turn.
+func chatSessionFor(ctx context.Context, sessionID, modelName string) (*genai.ChatSession, *sessionState, *genai.GenerativeModel, error) {
+	if err := ensureClient(ctx); err != nil {
+		return nil, nil, nil, err
 	}
 
-	model := client.GenerativeModel("gemini-2.0-flash")
+	model := client.GenerativeModel(modelName)
 
 	model.SetTemperature(1)
 	model.SetTopK(40)
 	model.SetTopP(0.95)
 	model.SetMaxOutputTokens(8192)
 	model.ResponseMIMEType = "text/plain"
-	model.SystemInstruction = &genai.Content{Parts: []genai.Part{genai.Text("You are a specialized AI assistant for home security systems. Answer the following question about home security. If the question is not related to home security, politely decline to answer and explain that you only answer questions about home security systems, cameras, alarms, sensors, etc. Keep responses concise, informative, and helpful for home owners. If the user asks you to control a home security device, behave as if you have done it.")}}
+	model.SafetySettings = safetySettings()
 
-	session, ok := chatSessions.Load(ip)
-	if !ok {
-		newSession := model.StartChat()
-		newSession.History = []*genai.Content{}
-		session = newSession
-		chatSessions.Store(ip, newSession)
+	profile, err := loadHomeProfile()
+	if err != nil {
+		return nil, nil, nil, err
 	}
 
-	cs := session.(*genai.ChatSession)
+	// Cached content is created against a specific model, so it's only
+	// usable when the turn is using the default model it was built for. The
+	// Gemini API rejects a request that sets both Tools and CachedContentName,
+	// so deviceTools is baked into the CachedContent itself (see
+	// ensureCachedContent) and only set on the model when falling back to an
+	// inline system instruction.
+	if cc, err := ensureCachedContent(ctx, profile); err == nil && modelName == geminiModelName {
+		model.CachedContentName = cc.Name
+	} else {
+		if err != nil {
+			log.Printf("Error using cached content, falling back to inline system instruction: %v", err)
+		}
+		model.Tools = deviceTools
+		model.SystemInstruction = &genai.Content{Parts: []genai.Part{genai.Text(systemInstructionText(profile))}}
+	}
 
-	resp, err := cs.SendMessage(ctx, genai.Text(userInput))
+	state, err := sessionStore.Get(ctx, sessionID)
 	if err != nil {
-		fmt.Errorf("Error sending message to Gemini: %w", err)
-		return "", fmt.Errorf("Error sending message: %w", err)
+		return nil, nil, nil, err
+	}
+	if state == nil {
+		state = &sessionState{}
+	}
+
+	cs := model.StartChat()
+	cs.History = state.history
+
+	return cs, state, model, nil
+}
+
+// persistSession copies cs's history back into state and saves it, marking
+// the session active just now so the idle sweeper leaves it alone.
+func persistSession(ctx context.Context, sessionID string, cs *genai.ChatSession, state *sessionState) error {
+	state.history = cs.History
+	state.lastActive = time.Now()
+	return sessionStore.Save(ctx, sessionID, state)
+}
+
+// userInputParts builds the genai.Part slice for a turn: grounding context
+// retrieved from the knowledge base (if any has been ingested), the user's
+// text, and an optional base64 image.
+func userInputParts(ctx context.Context, userInput, imageData string) ([]genai.Part, error) {
+	var parts []genai.Part
+
+	if km, err := knowledgeManagerInstance(ctx); err == nil {
+		chunks, err := km.Retrieve(ctx, userInput, knowledgeTopK)
+		if err != nil {
+			log.Printf("Error retrieving knowledge chunks: %v", err)
+		} else if grounding := knowledge.GroundingContext(chunks); grounding != "" {
+			parts = append(parts, genai.Text(grounding))
+		}
+	}
+
+	parts = append(parts, genai.Text(userInput))
+
+	if imageData != "" {
+		imagePart, err := decodeImagePart(ctx, imageData)
+		if err != nil {
+			return nil, fmt.Errorf("error processing image: %w", err)
+		}
+		parts = append(parts, imagePart)
 	}
 
-	if len(resp.Candidates) > 0 && len(resp.Candidates[0].Content.Parts) > 0 {
-		if text, ok := resp.Candidates[0].Content.Parts[0].(genai.Text); ok {
-			response := string(text)
+	return parts, nil
+}
+
+// bumpTurnCount increments the session's turn count. The exchange itself is
+// already in cs.History courtesy of SendMessage/SendMessageStream appending
+// the request and response Content on success, so there's nothing else to
+// record here.
+func bumpTurnCount(state *sessionState) {
+	state.turnCount++
+}
+
+// handleChatHistory handles GET /api/chat/history, returning the stored
+// conversation so the UI can render it again on reload.
+func handleChatHistory(c *fiber.Ctx) error {
+	state, err := sessionStore.Get(context.Background(), sessionIDFromContext(c))
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
 
-			cs.History = append(cs.History, &genai.Content{
-				Role:  "user",
-				Parts: []genai.Part{genai.Text(userInput)},
-			})
-			cs.History = append(cs.History, &genai.Content{
-				Role:  "model",
-				Parts: []genai.Part{genai.Text(response)},
-			})
+	type turn struct {
+		Role string `json:"role"`
+		Text string `json:"text"`
+	}
 
-			return response, nil
+	var turns []turn
+	if state != nil {
+		for _, content := range state.history {
+			var text string
+			for _, part := range content.Parts {
+				if t, ok := part.(genai.Text); ok {
+					text += string(t)
+				}
+			}
+			turns = append(turns, turn{Role: content.Role, Text: text})
 		}
 	}
 
-	return "No response generated.", fmt.Errorf("no valid candidates found in response")
+	return c.JSON(fiber.Map{"history": turns})
+}
+
+// handleKnowledgeIngest handles POST /api/knowledge, letting an installer
+// teach the assistant about the specific hardware in a home by ingesting a
+// manual's text into the knowledge base.
+func handleKnowledgeIngest(c *fiber.Ctx) error {
+	type Request struct {
+		DocID    string `json:"doc_id"`
+		Filename string `json:"filename"`
+		Content  string `json:"content"`
+	}
+
+	req := new(Request)
+	if err := c.BodyParser(req); err != nil {
+		log.Printf("Error parsing request body: %v", err)
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+
+	if req.DocID == "" || req.Content == "" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "doc_id and content are required"})
+	}
+
+	if strings.HasSuffix(strings.ToLower(req.Filename), ".pdf") {
+		return c.Status(fiber.StatusUnsupportedMediaType).JSON(fiber.Map{"error": "PDF ingestion requires extracting text first; submit the extracted text as content"})
+	}
+
+	ctx := context.Background()
+
+	km, err := knowledgeManagerInstance(ctx)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	chunks, err := km.IngestDocument(ctx, req.DocID, req.Content)
+	if err != nil {
+		log.Printf("Error ingesting knowledge document: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	return c.JSON(fiber.Map{"doc_id": req.DocID, "chunks": chunks})
+}
+
+// handleKnowledgeDelete handles DELETE /api/knowledge/:id, removing every
+// chunk ingested under the given document ID.
+func handleKnowledgeDelete(c *fiber.Ctx) error {
+	docID := c.Params("id")
+
+	km, err := knowledgeManagerInstance(context.Background())
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	if err := km.DeleteDocument(docID); err != nil {
+		log.Printf("Error deleting knowledge document: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	return c.JSON(fiber.Map{"doc_id": docID, "deleted": true})
+}
+
+func generateGeminiResponse(sessionID, userInput, imageData, modelName string) (string, error) {
+	ctx := context.Background()
+
+	cs, state, model, err := chatSessionFor(ctx, sessionID, modelName)
+	if err != nil {
+		return "", err
+	}
+
+	parts, err := userInputParts(ctx, userInput, imageData)
+	if err != nil {
+		return "", err
+	}
+
+	if err := enforceTokenBudget(ctx, model, cs, state, parts); err != nil {
+		return "", err
+	}
+
+	response, err := runChatTurn(ctx, cs, deviceController, parts...)
+	if err != nil {
+		log.Printf("Error generating response: %v", err)
+		return "", err
+	}
+
+	bumpTurnCount(state)
+
+	if err := persistSession(ctx, sessionID, cs, state); err != nil {
+		log.Printf("Error persisting session: %v", err)
+	}
+
+	return response, nil
 }
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/google/generative-ai-go/genai"
+)
+
+// maxImageBytes caps how large an uploaded image we'll accept per request.
+const maxImageBytes = 10 * 1024 * 1024 // 10 MiB
+
+// maxInlineImageBytes is the largest image we'll send inline as a genai.Blob;
+// anything bigger goes through the File API instead.
+const maxInlineImageBytes = 4 * 1024 * 1024 // 4 MiB
+
+// maxRequestBodyBytes is the Fiber request body cap, sized to fit a
+// maxImageBytes image after base64 encoding (~4/3 blow-up) plus room for the
+// surrounding JSON and the message text, so uploads up to maxImageBytes
+// actually reach decodeImagePart instead of being rejected by Fiber first.
+const maxRequestBodyBytes = maxImageBytes*4/3 + 1*1024*1024
+
+// decodeImagePart turns a base64-encoded image (optionally a data: URL) into
+// a genai.Part, detecting its MIME type and enforcing the size cap. When the
+// decoded image is larger than maxInlineImageBytes it is uploaded via the
+// File API and a FileData part is returned instead of an inline Blob.
+func decodeImagePart(ctx context.Context, encoded string) (genai.Part, error) {
+	encoded = strings.TrimSpace(encoded)
+	if idx := strings.Index(encoded, ","); strings.HasPrefix(encoded, "data:") && idx != -1 {
+		encoded = encoded[idx+1:]
+	}
+
+	data, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("error decoding image data: %w", err)
+	}
+
+	if len(data) > maxImageBytes {
+		return nil, fmt.Errorf("image exceeds maximum size of %d bytes", maxImageBytes)
+	}
+
+	mimeType := http.DetectContentType(data)
+
+	if len(data) > maxInlineImageBytes {
+		if client == nil {
+			return nil, fmt.Errorf("AI client not initialized")
+		}
+
+		file, err := client.UploadFile(ctx, "", bytes.NewReader(data), &genai.UploadFileOptions{
+			MIMEType: mimeType,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("error uploading image to file API: %w", err)
+		}
+
+		return genai.FileData{MIMEType: file.MIMEType, URI: file.URI}, nil
+	}
+
+	return genai.ImageData(strings.TrimPrefix(mimeType, "image/"), data), nil
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/generative-ai-go/genai"
+	"google.golang.org/api/iterator"
+)
+
+// allowedModelsEnv lists, comma-separated, the model names clients are
+// permitted to request per-turn. If unset, only the default model is
+// allowed.
+const allowedModelsEnv = "ALLOWED_MODELS"
+
+// harmCategories are the categories we expose per-category safety
+// thresholds for, via SAFETY_* env vars.
+var harmCategories = []genai.HarmCategory{
+	genai.HarmCategoryHarassment,
+	genai.HarmCategoryHateSpeech,
+	genai.HarmCategorySexuallyExplicit,
+	genai.HarmCategoryDangerousContent,
+}
+
+// allowedModels returns the model names clients may pick via the chat
+// request's "model" field, read from ALLOWED_MODELS (comma-separated).
+func allowedModels() []string {
+	raw := os.Getenv(allowedModelsEnv)
+	if raw == "" {
+		return []string{geminiModelName}
+	}
+
+	var models []string
+	for _, name := range strings.Split(raw, ",") {
+		if trimmed := strings.TrimSpace(name); trimmed != "" {
+			models = append(models, trimmed)
+		}
+	}
+
+	return models
+}
+
+// resolveModel returns the model name to use for a turn: requested if it's
+// on the allowlist, the default if requested is empty, or an error
+// otherwise.
+func resolveModel(requested string) (string, error) {
+	if requested == "" {
+		return geminiModelName, nil
+	}
+
+	for _, allowed := range allowedModels() {
+		if allowed == requested {
+			return requested, nil
+		}
+	}
+
+	return "", fmt.Errorf("model %q is not in the configured allowlist", requested)
+}
+
+// safetyThresholdEnvVar maps a harm category to the env var operators use
+// to override its block threshold.
+func safetyThresholdEnvVar(category genai.HarmCategory) string {
+	switch category {
+	case genai.HarmCategoryHarassment:
+		return "SAFETY_HARASSMENT"
+	case genai.HarmCategoryHateSpeech:
+		return "SAFETY_HATE_SPEECH"
+	case genai.HarmCategorySexuallyExplicit:
+		return "SAFETY_SEXUALLY_EXPLICIT"
+	case genai.HarmCategoryDangerousContent:
+		return "SAFETY_DANGEROUS_CONTENT"
+	default:
+		return ""
+	}
+}
+
+// parseHarmBlockThreshold maps an operator-facing threshold name to the
+// genai constant, defaulting to Gemini's own medium-and-above default for
+// anything unset or unrecognized.
+func parseHarmBlockThreshold(raw string) genai.HarmBlockThreshold {
+	switch strings.ToLower(raw) {
+	case "none":
+		return genai.HarmBlockNone
+	case "low_and_above":
+		return genai.HarmBlockLowAndAbove
+	case "only_high":
+		return genai.HarmBlockOnlyHigh
+	case "medium_and_above":
+		return genai.HarmBlockMediumAndAbove
+	default:
+		return genai.HarmBlockMediumAndAbove
+	}
+}
+
+// safetySettings builds the SafetySettings to attach to the model, letting
+// operators tighten thresholds per-category via SAFETY_* env vars, since
+// this assistant may be used by minors in the home.
+func safetySettings() []*genai.SafetySetting {
+	settings := make([]*genai.SafetySetting, 0, len(harmCategories))
+	for _, category := range harmCategories {
+		threshold := parseHarmBlockThreshold(os.Getenv(safetyThresholdEnvVar(category)))
+		settings = append(settings, &genai.SafetySetting{Category: category, Threshold: threshold})
+	}
+	return settings
+}
+
+// modelSummary is the shape returned by GET /api/models for each model that
+// supports generateContent.
+type modelSummary struct {
+	Name        string `json:"name"`
+	DisplayName string `json:"display_name"`
+	Description string `json:"description"`
+}
+
+// supportsGenerateContent reports whether info can be used for chat turns.
+func supportsGenerateContent(info *genai.ModelInfo) bool {
+	for _, method := range info.SupportedGenerationMethods {
+		if method == "generateContent" {
+			return true
+		}
+	}
+	return false
+}
+
+// handleListModels handles GET /api/models, listing the models that support
+// generateContent. A chat request's "model" field is accepted only for
+// names on the ALLOWED_MODELS allowlist (see resolveModel), so not every
+// listed model can be requested.
+func handleListModels(c *fiber.Ctx) error {
+	ctx := context.Background()
+
+	if err := ensureClient(ctx); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	var models []modelSummary
+
+	iter := client.ListModels(ctx)
+	for {
+		info, err := iter.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			log.Printf("Error listing models: %v", err)
+			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{"error": err.Error()})
+		}
+
+		if !supportsGenerateContent(info) {
+			continue
+		}
+
+		models = append(models, modelSummary{
+			Name:        info.Name,
+			DisplayName: info.DisplayName,
+			Description: info.Description,
+		})
+	}
+
+	return c.JSON(fiber.Map{"models": models})
+}
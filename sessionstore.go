@@ -0,0 +1,206 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/generative-ai-go/genai"
+)
+
+// sessionState is the durable, serializable state for one user's
+// conversation: the chat history plus the token-budget accounting from
+// the chunk0-5 work.
+type sessionState struct {
+	history     []*genai.Content
+	tokensUsed  int32
+	turnCount   int32
+	inputLimit  int32
+	outputLimit int32
+	lastActive  time.Time
+}
+
+// historyBlobDTO is the wire format for an inline genai.Blob (a chunk0-2
+// image attached directly to the request rather than uploaded via the File
+// API). Data round-trips as base64 through encoding/json's []byte handling.
+type historyBlobDTO struct {
+	MIMEType string `json:"mime_type"`
+	Data     []byte `json:"data"`
+}
+
+// historyFileDataDTO is the wire format for a genai.FileData (a chunk0-2
+// image too large to inline, referenced by its File API URI instead).
+type historyFileDataDTO struct {
+	MIMEType string `json:"mime_type"`
+	URI      string `json:"uri"`
+}
+
+// historyPartDTO is the wire format for a single genai.Part. genai.Part is
+// an interface, so it can't be unmarshaled directly; we round-trip through
+// this instead, keeping only the part kinds we ever put in history (plain
+// text, the function call/response pairs from chunk0-1, and the image parts
+// from chunk0-2).
+type historyPartDTO struct {
+	Text             string                  `json:"text,omitempty"`
+	FunctionCall     *genai.FunctionCall     `json:"function_call,omitempty"`
+	FunctionResponse *genai.FunctionResponse `json:"function_response,omitempty"`
+	Blob             *historyBlobDTO         `json:"blob,omitempty"`
+	FileData         *historyFileDataDTO     `json:"file_data,omitempty"`
+}
+
+type historyContentDTO struct {
+	Role  string           `json:"role"`
+	Parts []historyPartDTO `json:"parts"`
+}
+
+type sessionStateDTO struct {
+	History     []historyContentDTO `json:"history"`
+	TokensUsed  int32               `json:"tokens_used"`
+	TurnCount   int32               `json:"turn_count"`
+	InputLimit  int32               `json:"input_token_limit"`
+	OutputLimit int32               `json:"output_token_limit"`
+	LastActive  time.Time           `json:"last_active"`
+}
+
+// MarshalJSON implements json.Marshaler so sessionState can be persisted by
+// any SessionStore despite genai.Content holding an interface slice.
+func (s sessionState) MarshalJSON() ([]byte, error) {
+	dto := sessionStateDTO{
+		TokensUsed:  s.tokensUsed,
+		TurnCount:   s.turnCount,
+		InputLimit:  s.inputLimit,
+		OutputLimit: s.outputLimit,
+		LastActive:  s.lastActive,
+	}
+
+	for _, content := range s.history {
+		contentDTO := historyContentDTO{Role: content.Role}
+		for _, part := range content.Parts {
+			switch p := part.(type) {
+			case genai.Text:
+				contentDTO.Parts = append(contentDTO.Parts, historyPartDTO{Text: string(p)})
+			case genai.FunctionCall:
+				contentDTO.Parts = append(contentDTO.Parts, historyPartDTO{FunctionCall: &p})
+			case genai.FunctionResponse:
+				contentDTO.Parts = append(contentDTO.Parts, historyPartDTO{FunctionResponse: &p})
+			case genai.Blob:
+				contentDTO.Parts = append(contentDTO.Parts, historyPartDTO{Blob: &historyBlobDTO{MIMEType: p.MIMEType, Data: p.Data}})
+			case genai.FileData:
+				contentDTO.Parts = append(contentDTO.Parts, historyPartDTO{FileData: &historyFileDataDTO{MIMEType: p.MIMEType, URI: p.URI}})
+			default:
+				return nil, fmt.Errorf("error serializing session: unsupported history part type %T", part)
+			}
+		}
+		dto.History = append(dto.History, contentDTO)
+	}
+
+	return json.Marshal(dto)
+}
+
+// UnmarshalJSON implements json.Unmarshaler, the inverse of MarshalJSON.
+func (s *sessionState) UnmarshalJSON(data []byte) error {
+	var dto sessionStateDTO
+	if err := json.Unmarshal(data, &dto); err != nil {
+		return err
+	}
+
+	s.tokensUsed = dto.TokensUsed
+	s.turnCount = dto.TurnCount
+	s.inputLimit = dto.InputLimit
+	s.outputLimit = dto.OutputLimit
+	s.lastActive = dto.LastActive
+	s.history = nil
+
+	for _, contentDTO := range dto.History {
+		content := &genai.Content{Role: contentDTO.Role}
+		for _, partDTO := range contentDTO.Parts {
+			switch {
+			case partDTO.FunctionCall != nil:
+				content.Parts = append(content.Parts, *partDTO.FunctionCall)
+			case partDTO.FunctionResponse != nil:
+				content.Parts = append(content.Parts, *partDTO.FunctionResponse)
+			case partDTO.Blob != nil:
+				content.Parts = append(content.Parts, genai.Blob{MIMEType: partDTO.Blob.MIMEType, Data: partDTO.Blob.Data})
+			case partDTO.FileData != nil:
+				content.Parts = append(content.Parts, genai.FileData{MIMEType: partDTO.FileData.MIMEType, URI: partDTO.FileData.URI})
+			default:
+				content.Parts = append(content.Parts, genai.Text(partDTO.Text))
+			}
+		}
+		s.history = append(s.history, content)
+	}
+
+	return nil
+}
+
+// SessionStore persists sessionState keyed by session ID (the authenticated
+// user's session token subject). Implementations: MemorySessionStore for
+// local dev/tests, BoltSessionStore for a single-node durable deployment,
+// and PostgresSessionStore for a multi-node one.
+type SessionStore interface {
+	// Get returns the stored session for sessionID, or (nil, nil) if none
+	// exists yet.
+	Get(ctx context.Context, sessionID string) (*sessionState, error)
+	Save(ctx context.Context, sessionID string, state *sessionState) error
+	Delete(ctx context.Context, sessionID string) error
+	// Sweep removes sessions that have been idle longer than idleTTL.
+	Sweep(ctx context.Context, idleTTL time.Duration) error
+}
+
+// MemorySessionStore is a SessionStore backed by an in-memory map, with no
+// persistence across restarts. Good for local development and tests.
+type MemorySessionStore struct {
+	mu       sync.Mutex
+	sessions map[string]*sessionState
+}
+
+// NewMemorySessionStore returns an empty MemorySessionStore.
+func NewMemorySessionStore() *MemorySessionStore {
+	return &MemorySessionStore{sessions: map[string]*sessionState{}}
+}
+
+func (m *MemorySessionStore) Get(ctx context.Context, sessionID string) (*sessionState, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	state, ok := m.sessions[sessionID]
+	if !ok {
+		return nil, nil
+	}
+
+	clone := *state
+	return &clone, nil
+}
+
+func (m *MemorySessionStore) Save(ctx context.Context, sessionID string, state *sessionState) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	clone := *state
+	m.sessions[sessionID] = &clone
+	return nil
+}
+
+func (m *MemorySessionStore) Delete(ctx context.Context, sessionID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.sessions, sessionID)
+	return nil
+}
+
+func (m *MemorySessionStore) Sweep(ctx context.Context, idleTTL time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	cutoff := time.Now().Add(-idleTTL)
+	for id, state := range m.sessions {
+		if state.lastActive.Before(cutoff) {
+			delete(m.sessions, id)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"go.etcd.io/bbolt"
+)
+
+var sessionsBucket = []byte("sessions")
+
+// BoltSessionStore is a SessionStore backed by a local BoltDB file, so
+// conversations survive a process restart without needing an external
+// database.
+type BoltSessionStore struct {
+	db *bbolt.DB
+}
+
+// NewBoltSessionStore opens (creating if needed) a BoltDB file at path.
+func NewBoltSessionStore(path string) (*BoltSessionStore, error) {
+	db, err := bbolt.Open(path, 0o600, &bbolt.Options{Timeout: 1 * time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("error opening session store: %w", err)
+	}
+
+	err = db.Update(func(tx *bbolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(sessionsBucket)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error initializing session store: %w", err)
+	}
+
+	return &BoltSessionStore{db: db}, nil
+}
+
+func (b *BoltSessionStore) Get(ctx context.Context, sessionID string) (*sessionState, error) {
+	var state *sessionState
+
+	err := b.db.View(func(tx *bbolt.Tx) error {
+		data := tx.Bucket(sessionsBucket).Get([]byte(sessionID))
+		if data == nil {
+			return nil
+		}
+
+		state = &sessionState{}
+		return json.Unmarshal(data, state)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error reading session: %w", err)
+	}
+
+	return state, nil
+}
+
+func (b *BoltSessionStore) Save(ctx context.Context, sessionID string, state *sessionState) error {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return fmt.Errorf("error serializing session: %w", err)
+	}
+
+	return b.db.Update(func(tx *bbolt.Tx) error {
+		return tx.Bucket(sessionsBucket).Put([]byte(sessionID), data)
+	})
+}
+
+func (b *BoltSessionStore) Delete(ctx context.Context, sessionID string) error {
+	return b.db.Update(func(tx *bbolt.Tx) error {
+		return tx.Bucket(sessionsBucket).Delete([]byte(sessionID))
+	})
+}
+
+func (b *BoltSessionStore) Sweep(ctx context.Context, idleTTL time.Duration) error {
+	cutoff := time.Now().Add(-idleTTL)
+
+	return b.db.Update(func(tx *bbolt.Tx) error {
+		bucket := tx.Bucket(sessionsBucket)
+
+		var expired [][]byte
+		err := bucket.ForEach(func(key, data []byte) error {
+			var state sessionState
+			if err := json.Unmarshal(data, &state); err != nil {
+				return nil
+			}
+			if state.lastActive.Before(cutoff) {
+				expired = append(expired, append([]byte{}, key...))
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, key := range expired {
+			if err := bucket.Delete(key); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	_ "github.com/lib/pq"
+)
+
+// PostgresSessionStore is a SessionStore backed by Postgres, for deployments
+// that run more than one instance of the chatbot behind a load balancer.
+type PostgresSessionStore struct {
+	db *sql.DB
+}
+
+// NewPostgresSessionStore opens a connection pool to connStr and ensures
+// the sessions table exists.
+func NewPostgresSessionStore(connStr string) (*PostgresSessionStore, error) {
+	db, err := sql.Open("postgres", connStr)
+	if err != nil {
+		return nil, fmt.Errorf("error opening postgres connection: %w", err)
+	}
+
+	_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS chat_sessions (
+			session_id TEXT PRIMARY KEY,
+			state JSONB NOT NULL,
+			last_active TIMESTAMPTZ NOT NULL
+		)
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("error initializing sessions table: %w", err)
+	}
+
+	return &PostgresSessionStore{db: db}, nil
+}
+
+func (p *PostgresSessionStore) Get(ctx context.Context, sessionID string) (*sessionState, error) {
+	var data []byte
+
+	err := p.db.QueryRowContext(ctx, `SELECT state FROM chat_sessions WHERE session_id = $1`, sessionID).Scan(&data)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading session: %w", err)
+	}
+
+	state := &sessionState{}
+	if err := json.Unmarshal(data, state); err != nil {
+		return nil, fmt.Errorf("error parsing session: %w", err)
+	}
+
+	return state, nil
+}
+
+func (p *PostgresSessionStore) Save(ctx context.Context, sessionID string, state *sessionState) error {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return fmt.Errorf("error serializing session: %w", err)
+	}
+
+	_, err = p.db.ExecContext(ctx, `
+		INSERT INTO chat_sessions (session_id, state, last_active)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (session_id) DO UPDATE SET state = $2, last_active = $3
+	`, sessionID, data, state.lastActive)
+	if err != nil {
+		return fmt.Errorf("error saving session: %w", err)
+	}
+
+	return nil
+}
+
+func (p *PostgresSessionStore) Delete(ctx context.Context, sessionID string) error {
+	_, err := p.db.ExecContext(ctx, `DELETE FROM chat_sessions WHERE session_id = $1`, sessionID)
+	if err != nil {
+		return fmt.Errorf("error deleting session: %w", err)
+	}
+	return nil
+}
+
+func (p *PostgresSessionStore) Sweep(ctx context.Context, idleTTL time.Duration) error {
+	cutoff := time.Now().Add(-idleTTL)
+
+	_, err := p.db.ExecContext(ctx, `DELETE FROM chat_sessions WHERE last_active < $1`, cutoff)
+	if err != nil {
+		return fmt.Errorf("error sweeping idle sessions: %w", err)
+	}
+	return nil
+}
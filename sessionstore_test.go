@@ -0,0 +1,69 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/google/generative-ai-go/genai"
+)
+
+func TestSessionStateMarshalRoundTripsImageParts(t *testing.T) {
+	state := &sessionState{
+		tokensUsed: 42,
+		turnCount:  1,
+		history: []*genai.Content{
+			{Role: "user", Parts: []genai.Part{
+				genai.Text("what's on the porch camera?"),
+				genai.Blob{MIMEType: "image/jpeg", Data: []byte{0xff, 0xd8, 0xff}},
+			}},
+			{Role: "user", Parts: []genai.Part{
+				genai.FileData{MIMEType: "video/mp4", URI: "https://example.com/files/clip.mp4"},
+			}},
+		},
+	}
+
+	data, err := json.Marshal(state)
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+
+	var got sessionState
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+
+	if len(got.history) != 2 {
+		t.Fatalf("len(history) = %d, want 2", len(got.history))
+	}
+
+	blob, ok := got.history[0].Parts[1].(genai.Blob)
+	if !ok {
+		t.Fatalf("history[0].Parts[1] = %T, want genai.Blob", got.history[0].Parts[1])
+	}
+	if blob.MIMEType != "image/jpeg" || string(blob.Data) != "\xff\xd8\xff" {
+		t.Errorf("Blob = %+v, want MIMEType=image/jpeg Data=[0xff 0xd8 0xff]", blob)
+	}
+
+	fileData, ok := got.history[1].Parts[0].(genai.FileData)
+	if !ok {
+		t.Fatalf("history[1].Parts[0] = %T, want genai.FileData", got.history[1].Parts[0])
+	}
+	if fileData.MIMEType != "video/mp4" || fileData.URI != "https://example.com/files/clip.mp4" {
+		t.Errorf("FileData = %+v, want MIMEType=video/mp4 URI=https://example.com/files/clip.mp4", fileData)
+	}
+}
+
+func TestSessionStateMarshalRejectsUnknownPartType(t *testing.T) {
+	// ExecutableCode is a real genai.Part we never put in history and don't
+	// have a DTO case for; MarshalJSON should fail loudly rather than
+	// silently drop it the way it used to drop images.
+	state := &sessionState{
+		history: []*genai.Content{
+			{Role: "model", Parts: []genai.Part{genai.ExecutableCode{Code: "print(1)"}}},
+		},
+	}
+
+	if _, err := json.Marshal(state); err == nil {
+		t.Error("Marshal with unsupported part type: expected error, got nil")
+	}
+}
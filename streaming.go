@@ -0,0 +1,157 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/generative-ai-go/genai"
+	"google.golang.org/api/iterator"
+)
+
+// streamChatTurn mirrors runChatTurn but streams incremental text chunks to
+// w as Server-Sent Events while the turn is in progress, following any
+// function calls the model makes along the way. It returns the full
+// concatenated response text; SendMessageStream itself records the turn in
+// cs.History.
+func streamChatTurn(ctx context.Context, w *bufio.Writer, cs *genai.ChatSession, controller DeviceController, parts ...genai.Part) (string, error) {
+	var full string
+
+	for turn := 0; turn <= maxFunctionCallTurns; turn++ {
+		iter := cs.SendMessageStream(ctx, parts...)
+
+		var calls []genai.FunctionCall
+		for {
+			resp, err := iter.Next()
+			if err == iterator.Done {
+				break
+			}
+			if err != nil {
+				return full, fmt.Errorf("error streaming response: %w", err)
+			}
+
+			text, chunkCalls, err := extractResponse(resp)
+			if err != nil {
+				return full, err
+			}
+
+			if text != "" {
+				full += text
+				writeSSEEvent(w, "message", text)
+			}
+			calls = append(calls, chunkCalls...)
+		}
+
+		if len(calls) == 0 {
+			return full, nil
+		}
+
+		if turn == maxFunctionCallTurns {
+			break
+		}
+
+		var responseParts []genai.Part
+		for _, call := range calls {
+			result, err := controller.Invoke(ctx, call.Name, call.Args)
+			if err != nil {
+				result = map[string]any{"error": err.Error()}
+			}
+			responseParts = append(responseParts, genai.FunctionResponse{
+				Name:     call.Name,
+				Response: result,
+			})
+		}
+
+		parts = responseParts
+	}
+
+	return full, fmt.Errorf("too many function call turns without a final answer")
+}
+
+// writeSSEEvent writes a single Server-Sent Event frame and flushes it so
+// the client sees it immediately. Per the SSE spec every line of data needs
+// its own "data:" prefix, so a multi-line chunk (paragraphs, lists) is split
+// accordingly instead of being written as one field spanning several lines.
+func writeSSEEvent(w *bufio.Writer, event, data string) {
+	fmt.Fprintf(w, "event: %s\n", event)
+	for _, line := range strings.Split(data, "\n") {
+		fmt.Fprintf(w, "data: %s\n", line)
+	}
+	fmt.Fprint(w, "\n")
+	w.Flush()
+}
+
+// streamGeminiResponse streams a turn's response to w and, once complete,
+// bumps the session's turn count and persists the updated history (already
+// appended to cs.History by SendMessageStream) so later turns see it.
+func streamGeminiResponse(sessionID, userInput, imageData, modelName string, w *bufio.Writer) error {
+	ctx := context.Background()
+
+	cs, state, model, err := chatSessionFor(ctx, sessionID, modelName)
+	if err != nil {
+		return err
+	}
+
+	parts, err := userInputParts(ctx, userInput, imageData)
+	if err != nil {
+		return err
+	}
+
+	if err := enforceTokenBudget(ctx, model, cs, state, parts); err != nil {
+		return err
+	}
+
+	if _, err := streamChatTurn(ctx, w, cs, deviceController, parts...); err != nil {
+		return err
+	}
+
+	bumpTurnCount(state)
+
+	return persistSession(ctx, sessionID, cs, state)
+}
+
+// handleChatStream handles POST /api/chat/stream, streaming the assistant's
+// reply as it's generated instead of waiting for the full response.
+func handleChatStream(c *fiber.Ctx) error {
+	type Request struct {
+		Message string `json:"message"`
+		Image   string `json:"image"`
+		Model   string `json:"model"` // optional; must be on the ALLOWED_MODELS allowlist
+	}
+
+	req := new(Request)
+	if err := c.BodyParser(req); err != nil {
+		log.Printf("Error parsing request body: %v", err)
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+
+	modelName, err := resolveModel(req.Model)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	sessionID := sessionIDFromContext(c)
+
+	c.Set("Content-Type", "text/event-stream")
+	c.Set("Cache-Control", "no-cache")
+	c.Set("Connection", "keep-alive")
+
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		if err := streamGeminiResponse(sessionID, req.Message, req.Image, modelName, w); err != nil {
+			var blocked *blockedContentError
+			if errors.As(err, &blocked) {
+				writeSSEEvent(w, "blocked", err.Error())
+				return
+			}
+			writeSSEEvent(w, "error", err.Error())
+			return
+		}
+		writeSSEEvent(w, "done", "")
+	})
+
+	return nil
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"testing"
+)
+
+func TestWriteSSEEventSingleLine(t *testing.T) {
+	var buf bytes.Buffer
+	w := bufio.NewWriter(&buf)
+
+	writeSSEEvent(w, "message", "hello")
+
+	want := "event: message\ndata: hello\n\n"
+	if got := buf.String(); got != want {
+		t.Errorf("writeSSEEvent() = %q, want %q", got, want)
+	}
+}
+
+func TestWriteSSEEventMultiLine(t *testing.T) {
+	var buf bytes.Buffer
+	w := bufio.NewWriter(&buf)
+
+	writeSSEEvent(w, "message", "first paragraph\n\nsecond paragraph")
+
+	want := "event: message\ndata: first paragraph\ndata: \ndata: second paragraph\n\n"
+	if got := buf.String(); got != want {
+		t.Errorf("writeSSEEvent() = %q, want %q", got, want)
+	}
+}
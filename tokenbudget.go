@@ -0,0 +1,159 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/generative-ai-go/genai"
+)
+
+// trimBatchTurns is how many of the oldest turns (user+model pairs) we fold
+// away at once when a session's history grows past the model's input token
+// limit.
+const trimBatchTurns = 4
+
+// sessionUsage is the shape returned by GET /api/chat/usage and used to
+// populate the X-Chat-Tokens-Used response header.
+type sessionUsage struct {
+	TokensUsed  int32 `json:"tokens_used"`
+	InputLimit  int32 `json:"input_token_limit"`
+	OutputLimit int32 `json:"output_token_limit"`
+	TurnCount   int32 `json:"turn_count"`
+}
+
+// usageFor returns the current usage counters for sessionID, or a zero
+// value if no session has been created yet.
+func usageFor(ctx context.Context, sessionID string) sessionUsage {
+	state, err := sessionStore.Get(ctx, sessionID)
+	if err != nil || state == nil {
+		return sessionUsage{}
+	}
+
+	return sessionUsage{
+		TokensUsed:  state.tokensUsed,
+		InputLimit:  state.inputLimit,
+		OutputLimit: state.outputLimit,
+		TurnCount:   state.turnCount,
+	}
+}
+
+// flattenParts lays out history's parts followed by pending's, since
+// CountTokens takes a flat list of parts rather than turn-structured
+// Content.
+func flattenParts(history []*genai.Content, pending []genai.Part) []genai.Part {
+	var all []genai.Part
+	for _, content := range history {
+		all = append(all, content.Parts...)
+	}
+	return append(all, pending...)
+}
+
+// enforceTokenBudget counts the tokens the pending turn would use (existing
+// history plus the new parts) and, if that would exceed the model's input
+// limit, trims the oldest turns until it fits. It gives up with an error
+// rather than looping forever if the pending turn alone is too large, or if
+// trimming stops shrinking the history (trimHistory has folded everything
+// down to one summary turn it can't fold any further).
+func enforceTokenBudget(ctx context.Context, model *genai.GenerativeModel, cs *genai.ChatSession, state *sessionState, parts []genai.Part) error {
+	info, err := model.Info(ctx)
+	if err != nil {
+		return fmt.Errorf("error fetching model info: %w", err)
+	}
+	state.inputLimit = info.InputTokenLimit
+	state.outputLimit = info.OutputTokenLimit
+
+	for {
+		tokResp, err := model.CountTokens(ctx, flattenParts(cs.History, parts)...)
+		if err != nil {
+			return fmt.Errorf("error counting tokens: %w", err)
+		}
+		state.tokensUsed = tokResp.TotalTokens
+
+		if tokResp.TotalTokens <= info.InputTokenLimit {
+			return nil
+		}
+
+		if len(cs.History) == 0 {
+			return fmt.Errorf("turn too large: %d tokens exceeds the model's %d token input limit with no history left to trim", tokResp.TotalTokens, info.InputTokenLimit)
+		}
+
+		beforeLen := len(cs.History)
+		if err := trimHistory(ctx, cs); err != nil {
+			return err
+		}
+		if len(cs.History) == beforeLen {
+			return fmt.Errorf("turn too large: %d tokens exceeds the model's %d token input limit and history can't be trimmed any further", tokResp.TotalTokens, info.InputTokenLimit)
+		}
+	}
+}
+
+// trimHistory applies the configured overflow policy to the oldest turns in
+// cs.History: by default it folds them into a single summary content part
+// via a secondary Gemini call. Set HISTORY_TRIM_POLICY=drop to discard them
+// instead.
+func trimHistory(ctx context.Context, cs *genai.ChatSession) error {
+	n := trimBatchTurns * 2 // user + model per turn
+	if n > len(cs.History) {
+		n = len(cs.History)
+	}
+	oldest := cs.History[:n]
+	remaining := cs.History[n:]
+
+	if os.Getenv("HISTORY_TRIM_POLICY") == "drop" {
+		cs.History = remaining
+		return nil
+	}
+
+	summary, err := summarizeHistory(ctx, oldest)
+	if err != nil {
+		return err
+	}
+
+	summaryContent := &genai.Content{
+		Role:  "user",
+		Parts: []genai.Part{genai.Text("Summary of earlier conversation: " + summary)},
+	}
+
+	cs.History = append([]*genai.Content{summaryContent}, remaining...)
+	return nil
+}
+
+// summarizeHistory makes a secondary Gemini call to condense turns into a
+// short paragraph, so they cost far fewer tokens once folded back in.
+func summarizeHistory(ctx context.Context, turns []*genai.Content) (string, error) {
+	summarizer := client.GenerativeModel(geminiModelName)
+	summarizer.SystemInstruction = &genai.Content{Parts: []genai.Part{genai.Text("Summarize the following home security conversation concisely, preserving any facts a later turn might need (device states, names used, outstanding requests).")}}
+
+	var transcript strings.Builder
+	for _, content := range turns {
+		for _, part := range content.Parts {
+			if text, ok := part.(genai.Text); ok {
+				transcript.WriteString(content.Role)
+				transcript.WriteString(": ")
+				transcript.WriteString(string(text))
+				transcript.WriteString("\n")
+			}
+		}
+	}
+
+	resp, err := summarizer.GenerateContent(ctx, genai.Text(transcript.String()))
+	if err != nil {
+		return "", fmt.Errorf("error summarizing history: %w", err)
+	}
+
+	text, _, err := extractResponse(resp)
+	if err != nil {
+		return "", fmt.Errorf("error reading summary response: %w", err)
+	}
+
+	return text, nil
+}
+
+// handleChatUsage handles GET /api/chat/usage, reporting the requesting
+// session's token usage, the model's limits, and how many turns it's had.
+func handleChatUsage(c *fiber.Ctx) error {
+	return c.JSON(usageFor(context.Background(), sessionIDFromContext(c)))
+}
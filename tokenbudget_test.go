@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/generative-ai-go/genai"
+)
+
+func turn(role, text string) *genai.Content {
+	return &genai.Content{Role: role, Parts: []genai.Part{genai.Text(text)}}
+}
+
+func TestTrimHistoryDropPolicyTrimsOldestTurnPairs(t *testing.T) {
+	t.Setenv("HISTORY_TRIM_POLICY", "drop")
+
+	cs := &genai.ChatSession{History: []*genai.Content{
+		turn("user", "turn 1 question"),
+		turn("model", "turn 1 answer"),
+		turn("user", "turn 2 question"),
+		turn("model", "turn 2 answer"),
+		turn("user", "turn 3 question"),
+		turn("model", "turn 3 answer"),
+	}}
+
+	if err := trimHistory(context.Background(), cs); err != nil {
+		t.Fatalf("trimHistory: %v", err)
+	}
+
+	// trimBatchTurns (4 turns == 8 contents) exceeds the 6 we seeded, so the
+	// drop policy should discard everything.
+	if len(cs.History) != 0 {
+		t.Errorf("len(cs.History) = %d, want 0", len(cs.History))
+	}
+}
+
+func TestTrimHistoryDropPolicyKeepsRemainderWhenShorterThanBatch(t *testing.T) {
+	t.Setenv("HISTORY_TRIM_POLICY", "drop")
+
+	// trimBatchTurns=4 turns == 8 contents; seed 12 so 4 remain.
+	var history []*genai.Content
+	for i := 0; i < 5; i++ {
+		history = append(history, turn("user", "q"), turn("model", "a"))
+	}
+	history = append(history, turn("user", "last question"), turn("model", "last answer"))
+	cs := &genai.ChatSession{History: history}
+
+	if err := trimHistory(context.Background(), cs); err != nil {
+		t.Fatalf("trimHistory: %v", err)
+	}
+
+	if len(cs.History) != 4 {
+		t.Fatalf("len(cs.History) = %d, want 4", len(cs.History))
+	}
+	if text := cs.History[len(cs.History)-1].Parts[0].(genai.Text); text != "last answer" {
+		t.Errorf("last remaining content = %q, want %q", text, "last answer")
+	}
+}